@@ -0,0 +1,207 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+)
+
+// connector creates the connections to a database. Its connections
+// share a Cloud Spanner client and a database admin client, which are
+// created when they are first needed and closed with the last open
+// connection. database/sql only closes connectors since Go 1.17, so the
+// clients must not depend on the connector being closed to be released.
+type connector struct {
+	driver   *Driver
+	name     string
+	database string
+	config   spanner.ClientConfig
+	opts     []option.ClientOption
+
+	// readOnlyStaleness and stringMode are the initial
+	// READ_ONLY_STALENESS and STRING_MODE of the connections.
+	readOnlyStaleness staleness
+	stringMode        bool
+
+	refs int // number of OpenConnector calls not closed yet, guarded by driver.mu
+
+	mu          sync.Mutex
+	conns       int // number of open connections
+	closed      bool
+	client      *spanner.Client
+	adminClient *adminapi.DatabaseAdminClient
+}
+
+func newConnector(d *Driver, name string) (*connector, error) {
+	database, params, err := parseDSN(name)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		readOnlyStaleness staleness
+		stringMode        bool
+	)
+	for k, v := range params {
+		switch strings.ToLower(k) {
+		case "readonlystaleness":
+			if readOnlyStaleness, err = parseStaleness(v); err != nil {
+				return nil, err
+			}
+		case "stringmode":
+			if stringMode, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("invalid stringMode %q: %v", v, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown connection parameter %q", k)
+		}
+	}
+
+	config := d.Config
+	if config.NumChannels == 0 {
+		config.NumChannels = 1 // TODO(jbd): Explain database/sql has a high-level management.
+	}
+	opts := append(d.Options[:len(d.Options):len(d.Options)], option.WithUserAgent(userAgent))
+	return &connector{
+		driver:            d,
+		name:              name,
+		database:          database,
+		config:            config,
+		opts:              opts,
+		readOnlyStaleness: readOnlyStaleness,
+		stringMode:        stringMode,
+	}, nil
+}
+
+// parseDSN splits name into the database name and the connection
+// parameters that follow it, separated by semicolons:
+//
+//	projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE;key=value;...
+func parseDSN(name string) (string, map[string]string, error) {
+	parts := strings.Split(name, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("invalid connection parameter %q", p)
+		}
+		params[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return strings.TrimSpace(parts[0]), params, nil
+}
+
+// Connect opens a connection. The Cloud Spanner client
+// is created with ctx by the first connection.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errors.New("connector is closed")
+	}
+	if c.client == nil {
+		client, err := spanner.NewClientWithConfig(ctx, c.database, c.config, c.opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	c.conns++
+	return &conn{
+		connector:         c,
+		client:            c.client,
+		database:          c.database,
+		readOnlyStaleness: c.readOnlyStaleness,
+		stringMode:        c.stringMode,
+	}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close releases the connector. Once all users of the connector have
+// released it, it opens no more connections, and the clients are closed
+// with its last connection.
+func (c *connector) Close() error {
+	d := c.driver
+	d.mu.Lock()
+	c.refs--
+	if c.refs > 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	delete(d.connectors, c.name)
+	d.mu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conns > 0 {
+		return nil // The last connection closes the clients.
+	}
+	return c.closeClients()
+}
+
+// releaseConn is called when a connection is closed. The clients are
+// closed with the last connection, and created again by the next one.
+func (c *connector) releaseConn() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns--
+	if c.conns == 0 {
+		c.closeClients()
+	}
+}
+
+// closeClients closes the clients. c.mu must be held.
+func (c *connector) closeClients() error {
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+	if c.adminClient != nil {
+		err := c.adminClient.Close()
+		c.adminClient = nil
+		return err
+	}
+	return nil
+}
+
+// getAdminClient returns the database admin client,
+// creating it with ctx on first use.
+func (c *connector) getAdminClient(ctx context.Context) (*adminapi.DatabaseAdminClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.adminClient == nil {
+		adminClient, err := newAdminClient(ctx, c.opts)
+		if err != nil {
+			return nil, err
+		}
+		c.adminClient = adminClient
+	}
+	return c.adminClient, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+
+	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc"
+)
+
+// execStatements executes statements that contain DDL or more than one
+// statement, such as migration files. Consecutive DDL statements are
+// sent to Cloud Spanner as a single schema update and consecutive DML
+// statements as a single batch.
+func (c *conn) execStatements(ctx context.Context, stmts []string) (driver.Result, error) {
+	var rowsAffected int64
+	for len(stmts) > 0 {
+		ddl := internal.IsDDL(stmts[0])
+		n := 1
+		for n < len(stmts) && internal.IsDDL(stmts[n]) == ddl {
+			n++
+		}
+		if ddl {
+			if err := c.execDDL(ctx, stmts[:n]); err != nil {
+				return nil, err
+			}
+		} else {
+			ss := make([]spanner.Statement, n)
+			for i, stmt := range stmts[:n] {
+				ss[i] = spanner.NewStatement(stmt)
+			}
+			counts, err := c.batchUpdate(ctx, ss)
+			if err != nil {
+				return nil, err
+			}
+			for _, count := range counts {
+				rowsAffected += count
+			}
+		}
+		stmts = stmts[n:]
+	}
+	return &result{rowsAffected: rowsAffected}, nil
+}
+
+// execDDL updates the schema of the database
+// and waits until the update is completed.
+func (c *conn) execDDL(ctx context.Context, stmts []string) error {
+	if c.inTransaction() {
+		return errors.New("cannot execute DDL in a transaction")
+	}
+	adminClient, err := c.connector.getAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   c.database,
+		Statements: stmts,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+func newAdminClient(ctx context.Context, opts []option.ClientOption) (*adminapi.DatabaseAdminClient, error) {
+	// The Cloud Spanner client connects to the emulator
+	// by itself but the admin client doesn't.
+	if emulatorAddr := os.Getenv("SPANNER_EMULATOR_HOST"); emulatorAddr != "" {
+		opts = append(opts[:len(opts):len(opts)],
+			option.WithoutAuthentication(),
+			option.WithEndpoint(emulatorAddr),
+			option.WithGRPCDialOption(grpc.WithInsecure()))
+	}
+	return adminapi.NewDatabaseAdminClient(ctx, opts...)
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"cloud.google.com/go/spanner"
+)
+
+// BatchResult is the result of a batch of DML statements
+// executed with ExecBatchDML.
+type BatchResult struct {
+	rowsAffected []int64
+}
+
+func (r *BatchResult) LastInsertId() (int64, error) {
+	return 0, errors.New("spanner doesn't autogenerate IDs")
+}
+
+// RowsAffected returns the total number of rows affected
+// by all statements of the batch.
+func (r *BatchResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, n := range r.rowsAffected {
+		total += n
+	}
+	return total, nil
+}
+
+// BatchRowsAffected returns the number of rows affected
+// by each statement of the batch, in execution order.
+func (r *BatchResult) BatchRowsAffected() []int64 {
+	return r.rowsAffected
+}
+
+// PartitionedDMLResult is the result of a statement
+// executed with ExecPartitionedDML.
+type PartitionedDMLResult struct {
+	lowerBound int64
+}
+
+func (r *PartitionedDMLResult) LastInsertId() (int64, error) {
+	return 0, errors.New("spanner doesn't autogenerate IDs")
+}
+
+// RowsAffected returns the same value as RowsAffectedLowerBound.
+func (r *PartitionedDMLResult) RowsAffected() (int64, error) {
+	return r.lowerBound, nil
+}
+
+// RowsAffectedLowerBound returns a lower bound of the number of
+// rows affected. Partitioned DML statements are executed once per
+// partition and possibly more than once, so the exact count isn't
+// known.
+func (r *PartitionedDMLResult) RowsAffectedLowerBound() int64 {
+	return r.lowerBound
+}
+
+// ExecBatchDML executes the DML statements in a single round trip.
+// The statements are executed in the current read-write transaction
+// of c, or in a new one if c is not in a transaction.
+// Use db.Conn to get a connection from a *sql.DB.
+func ExecBatchDML(ctx context.Context, c *sql.Conn, stmts []spanner.Statement) (*BatchResult, error) {
+	var rowsAffected []int64
+	err := withConn(c, func(c *conn) error {
+		var err error
+		rowsAffected, err = c.batchUpdate(ctx, stmts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BatchResult{rowsAffected: rowsAffected}, nil
+}
+
+func (c *conn) batchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	if c.roTx != nil {
+		return nil, errors.New("cannot write in read-only transaction")
+	}
+	if c.rwTx != nil {
+		return c.rwTx.BatchUpdate(ctx, stmts)
+	}
+	var rowsAffected []int64
+	_, err := c.client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		var err error
+		rowsAffected, err = tx.BatchUpdate(ctx, stmts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rowsAffected, nil
+}
+
+// ExecPartitionedDML executes the statement as Partitioned DML.
+// Partitioned DML cannot be executed in a transaction.
+// Use db.Conn to get a connection from a *sql.DB.
+func ExecPartitionedDML(ctx context.Context, c *sql.Conn, stmt spanner.Statement) (*PartitionedDMLResult, error) {
+	var count int64
+	err := withConn(c, func(c *conn) error {
+		if c.inTransaction() {
+			return errors.New("cannot execute partitioned DML in a transaction")
+		}
+		var err error
+		count, err = c.client.PartitionedUpdate(ctx, stmt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PartitionedDMLResult{lowerBound: count}, nil
+}
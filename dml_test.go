@@ -0,0 +1,75 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBatchResult(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            []int64
+		wantRowsAffected int64
+	}{
+		{
+			name:             "empty batch",
+			input:            nil,
+			wantRowsAffected: 0,
+		},
+		{
+			name:             "single statement",
+			input:            []int64{3},
+			wantRowsAffected: 3,
+		},
+		{
+			name:             "several statements",
+			input:            []int64{1, 0, 5},
+			wantRowsAffected: 6,
+		},
+	}
+
+	for _, tc := range tests {
+		r := &BatchResult{rowsAffected: tc.input}
+		got, err := r.RowsAffected()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if got != tc.wantRowsAffected {
+			t.Errorf("Test failed: %s. want: %v, got: %v", tc.name, tc.wantRowsAffected, got)
+		}
+		if !reflect.DeepEqual(tc.input, r.BatchRowsAffected()) {
+			t.Errorf("Test failed: %s. want batch: %v, got: %v", tc.name, tc.input, r.BatchRowsAffected())
+		}
+		if _, err := r.LastInsertId(); err == nil {
+			t.Errorf("%s: expected LastInsertId error", tc.name)
+		}
+	}
+}
+
+func TestPartitionedDMLResult(t *testing.T) {
+	r := &PartitionedDMLResult{lowerBound: 42}
+	got, err := r.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 || r.RowsAffectedLowerBound() != 42 {
+		t.Errorf("want: 42, got: %v and lower bound %v", got, r.RowsAffectedLowerBound())
+	}
+	if _, err := r.LastInsertId(); err == nil {
+		t.Errorf("expected LastInsertId error")
+	}
+}
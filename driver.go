@@ -19,9 +19,13 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"github.com/golang/protobuf/proto"
 	"github.com/rakyll/go-sql-driver-spanner/internal"
 	"google.golang.org/api/option"
 )
@@ -34,6 +38,30 @@ func init() {
 	sql.Register("spanner", &Driver{})
 }
 
+// Config is the configuration of a driver
+// registered with RegisterDriverWithName.
+type Config struct {
+	// ClientConfig represents the optional advanced configuration
+	// to be used by the Google Cloud Spanner client.
+	ClientConfig spanner.ClientConfig
+
+	// Options represent the optional Google Cloud client options,
+	// such as credentials or gRPC interceptors, to be passed to the
+	// underlying clients.
+	Options []option.ClientOption
+}
+
+// RegisterDriverWithName registers a driver with the given configuration
+// as name, which can then be used as the driver name of sql.Open.
+// Use it to open databases with differently configured clients in the
+// same program. Like sql.Register, it panics if name is already used.
+func RegisterDriverWithName(name string, config Config) {
+	sql.Register(name, &Driver{
+		Config:  config.ClientConfig,
+		Options: config.Options,
+	})
+}
+
 // Driver represents a Google Cloud Spanner database/sql driver.
 type Driver struct {
 	// Config represents the optional advanced configuration to be used
@@ -43,52 +71,87 @@ type Driver struct {
 	// Options represent the optional Google Cloud client options
 	// to be passed to the underlying client.
 	Options []option.ClientOption
+
+	mu         sync.Mutex
+	connectors map[string]*connector // by name
 }
 
 // Open opens a connection to a Google Cloud Spanner database.
 // Use fully qualified string:
 //
 // Example: projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE
+//
+// Connection parameters can follow the database name, separated by
+// semicolons. The readOnlyStaleness and stringMode parameters set the
+// initial READ_ONLY_STALENESS and STRING_MODE of the connections.
+//
+// Example: projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE;readOnlyStaleness=MAX_STALENESS 15s
 func (d *Driver) Open(name string) (driver.Conn, error) {
-	return openDriverConn(context.Background(), d, name)
+	c, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	dc, err := c.Connect(context.Background())
+	if err != nil {
+		c.(*connector).Close()
+		return nil, err
+	}
+	// The connection is the only user of the connector.
+	dc.(*conn).closeConnector = true
+	return dc, nil
 }
 
+// OpenConnector returns a connector to the database name. The connectors
+// of the same name share a single Cloud Spanner client, which is closed
+// when none of their connections is open.
 func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
-	return &connector{
-		driver: d,
-		name:   name,
-	}, nil
-}
-
-type connector struct {
-	driver *Driver
-	name   string
-}
-
-func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
-	return openDriverConn(ctx, c.driver, c.name)
-}
-
-func openDriverConn(ctx context.Context, d *Driver, name string) (driver.Conn, error) {
-	if d.Config.NumChannels == 0 {
-		d.Config.NumChannels = 1 // TODO(jbd): Explain database/sql has a high-level management.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.connectors[name]; ok {
+		c.refs++
+		return c, nil
+	}
+	if d.connectors == nil {
+		d.connectors = make(map[string]*connector)
 	}
-	opts := append(d.Options, option.WithUserAgent(userAgent))
-	client, err := spanner.NewClientWithConfig(ctx, name, d.Config, opts...)
+	c, err := newConnector(d, name)
 	if err != nil {
 		return nil, err
 	}
-	return &conn{client: client}, nil
-}
-
-func (c *connector) Driver() driver.Driver {
-	return &Driver{}
+	c.refs = 1
+	d.connectors[name] = c
+	return c, nil
 }
 
 type conn struct {
-	client *spanner.Client
-	roTx   *spanner.ReadOnlyTransaction
-	rwTx   *rwTx
+	connector *connector
+	client    *spanner.Client
+	database  string
+
+	// closeConnector is set if the connection was opened with
+	// Driver.Open and closes its connector when it is closed.
+	closeConnector bool
+
+	roTx *spanner.ReadOnlyTransaction
+	rwTx *rwTx
+
+	// tx is the transaction started with the BEGIN statement, if any.
+	tx driver.Tx
+	// txReadOnly is the mode of the next transaction
+	// started with BEGIN, set by SET TRANSACTION.
+	txReadOnly bool
+
+	// readOnlyStaleness is the timestamp bound of
+	// the queries executed outside of a transaction.
+	readOnlyStaleness staleness
+
+	// stringMode returns all columns of
+	// query results as strings if set.
+	stringMode bool
+
+	// readTimestamp is the read timestamp of the last
+	// read-only transaction or single-use query.
+	readTimestamp time.Time
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
@@ -104,7 +167,41 @@ func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 	return &stmt{conn: c, query: query, numArgs: len(args)}, nil
 }
 
+// CheckNamedValue deterministically encodes protobuf messages passed as
+// arguments and leaves the other values to the default converter.
+//
+// Messages are sent as BYTES; the Cloud Spanner client used by
+// the driver doesn't support PROTO typed values yet.
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	m, ok := v.Value.(proto.Message)
+	if !ok {
+		return driver.ErrSkip
+	}
+	if rv := reflect.ValueOf(m); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		v.Value = []byte(nil) // NULL
+		return nil
+	}
+	// Deterministic encoding, so that equal messages
+	// can be matched by comparing their bytes.
+	var b proto.Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(m); err != nil {
+		return err
+	}
+	v.Value = b.Bytes()
+	return nil
+}
+
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if cs, params := parseClientSideStatement(query); cs != nil {
+		return cs.execContext(ctx, c, params, args)
+	}
+	if stmts := internal.SplitStatements(query); len(stmts) > 1 || len(stmts) == 1 && internal.IsDDL(stmts[0]) {
+		if len(args) > 0 {
+			return nil, errors.New("arguments are not supported with DDL or multiple statements")
+		}
+		return c.execStatements(ctx, stmts)
+	}
 	if c.roTx != nil {
 		return nil, errors.New("cannot write in read-only transaction")
 	}
@@ -125,8 +222,78 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 	return &result{rowsAffected: rowsAffected}, nil
 }
 
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if cs, params := parseClientSideStatement(query); cs != nil {
+		return cs.queryContext(ctx, c, params, args)
+	}
+	ss, err := prepareSpannerStmt(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := timestampBoundFromContext(ctx); ok && c.inTransaction() {
+		return nil, errors.New("timestamp bounds of single-use queries cannot be used in a transaction")
+	}
+	if c.roTx != nil {
+		return &rows{it: c.roTx.Query(ctx, ss), stringMode: c.stringMode}, nil
+	}
+	if c.rwTx != nil {
+		return &rows{it: c.rwTx.Query(ctx, ss), stringMode: c.stringMode}, nil
+	}
+	c.readTimestamp = time.Time{}
+	ro := c.client.Single().WithTimestampBound(c.singleUseTimestampBound(ctx))
+	return &rows{
+		it:         ro.Query(ctx, ss),
+		stringMode: c.stringMode,
+		close: func() {
+			if ts, err := ro.Timestamp(); err == nil {
+				c.readTimestamp = ts
+			}
+		},
+	}, nil
+}
+
+// singleUseTimestampBound returns the timestamp bound of a query executed
+// outside of a transaction. The bound of ctx takes precedence over the
+// READ_ONLY_STALENESS of the connection.
+func (c *conn) singleUseTimestampBound(ctx context.Context) spanner.TimestampBound {
+	if tb, ok := timestampBoundFromContext(ctx); ok {
+		return tb
+	}
+	return c.readOnlyStaleness.tb
+}
+
+// ResetSession rolls back a transaction left open by BEGIN and restores
+// the connection variables to their initial values before database/sql
+// reuses the connection.
+func (c *conn) ResetSession(ctx context.Context) error {
+	var err error
+	if c.tx != nil && c.inTransaction() {
+		err = c.tx.Rollback()
+	}
+	c.tx = nil
+	c.txReadOnly = false
+	c.readOnlyStaleness = c.connector.readOnlyStaleness
+	c.stringMode = c.connector.stringMode
+	c.readTimestamp = time.Time{}
+	if err != nil {
+		// The state of the transaction is unknown, don't reuse the connection.
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
 func (c *conn) Close() error {
-	c.client.Close()
+	// Roll back a transaction started with BEGIN
+	// unless it has already finished.
+	if c.tx != nil && c.inTransaction() {
+		c.tx.Rollback()
+	}
+	c.tx = nil
+	c.connector.releaseConn()
+	if c.closeConnector {
+		return c.connector.Close()
+	}
 	return nil
 }
 
@@ -139,14 +306,26 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 		return nil, errors.New("already in a transaction")
 	}
 
+	c.readTimestamp = time.Time{}
+	txOpts := txOptionsFromContext(ctx)
 	if opts.ReadOnly {
-		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(spanner.StrongRead())
+		if singleUseOnly(txOpts.TimestampBound) {
+			return nil, fmt.Errorf("timestamp bound %v is only supported for single-use reads", txOpts.TimestampBound)
+		}
+		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(txOpts.TimestampBound)
 		return &roTx{close: func() {
+			if ts, err := c.roTx.Timestamp(); err == nil {
+				c.readTimestamp = ts
+			}
 			c.roTx.Close()
 			c.roTx = nil
 		}}, nil
 	}
 
+	if txOpts.TimestampBound != spanner.StrongRead() {
+		return nil, errors.New("timestamp bounds are only supported in read-only transactions")
+	}
+
 	connector := internal.NewRWConnector(ctx, c.client)
 	c.rwTx = &rwTx{
 		connector: connector,
@@ -167,10 +346,44 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 	}
 }
 
+// ReadTimestamp returns the timestamp Cloud Spanner chose for the
+// current or last read-only transaction, or for the last query
+// executed outside of a transaction on c.
+func ReadTimestamp(c *sql.Conn) (time.Time, error) {
+	var ts time.Time
+	err := withConn(c, func(c *conn) error {
+		ts = c.lastReadTimestamp()
+		if ts.IsZero() {
+			return errors.New("no read timestamp available")
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func (c *conn) lastReadTimestamp() time.Time {
+	if c.roTx != nil {
+		ts, _ := c.roTx.Timestamp()
+		return ts
+	}
+	return c.readTimestamp
+}
+
 func (c *conn) inTransaction() bool {
 	return c.roTx != nil || c.rwTx != nil
 }
 
+// withConn calls f with the Cloud Spanner connection underlying c.
+func withConn(c *sql.Conn, f func(c *conn) error) error {
+	return c.Raw(func(driverConn interface{}) error {
+		sc, ok := driverConn.(*conn)
+		if !ok {
+			return errors.New("not a Cloud Spanner connection")
+		}
+		return f(sc)
+	})
+}
+
 func (c *conn) execContextInNewRWTransaction(ctx context.Context, statement spanner.Statement) (int64, error) {
 	var rowsAffected int64
 	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
@@ -15,17 +15,26 @@
 package spannerdriver
 
 import (
+	"bytes"
 	"cloud.google.com/go/spanner"
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	// API/lib packages not imported by driver.
 	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	"google.golang.org/api/option"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 	"google.golang.org/grpc"
 )
 
@@ -302,3 +311,561 @@ func TestQueryContext(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestReadTimestamp(t *testing.T) {
+
+	ctx := context.Background()
+	db, err := sql.Open("spanner", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name string
+		read func() error
+	}{
+		{
+			name: "single-use query",
+			read: func() error {
+				rows, err := c.QueryContext(ctx, "SELECT 1")
+				if err != nil {
+					return err
+				}
+				for rows.Next() {
+				}
+				return rows.Close()
+			},
+		},
+		{
+			name: "read-only transaction",
+			read: func() error {
+				tx, err := c.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+				if err != nil {
+					return err
+				}
+				var n int64
+				if err := tx.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil {
+					tx.Rollback()
+					return err
+				}
+				return tx.Commit()
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		before := time.Now()
+		if err := tc.read(); err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		ts, err := ReadTimestamp(c)
+		if err != nil {
+			t.Errorf("%s: ReadTimestamp: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if ts.Before(before.Add(-time.Minute)) {
+			t.Errorf("%s: ReadTimestamp: got %v, want a timestamp of the read", tc.name, ts)
+		}
+
+		var shown sql.NullTime
+		if err := c.QueryRowContext(ctx, "SHOW VARIABLE READ_TIMESTAMP").Scan(&shown); err != nil {
+			t.Errorf("%s: SHOW VARIABLE READ_TIMESTAMP: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !shown.Time.Equal(ts) {
+			t.Errorf("%s: SHOW VARIABLE READ_TIMESTAMP: want %v, got %v", tc.name, ts, shown.Time)
+		}
+	}
+}
+
+func TestExecContextDDL(t *testing.T) {
+
+	conn, err := NewConnector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Open db.
+	ctx := context.Background()
+	db, err := sql.Open("spanner", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Create the table and index with a migration file.
+	res, err := db.ExecContext(ctx, `
+-- Schema.
+CREATE TABLE TestExecContextDDL (
+	A   STRING(1024),
+	B   STRING(1024)
+)	 PRIMARY KEY (A);
+CREATE INDEX TestExecContextDDLByB ON TestExecContextDDL (B);
+
+/* Seed; two rows. */
+INSERT INTO TestExecContextDDL (A, B) VALUES ("a1", "b;1");
+INSERT INTO TestExecContextDDL (A, B) VALUES ("a2", "b;2");
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := res.RowsAffected(); got != 2 {
+		t.Errorf("RowsAffected: want: 2, got: %v", got)
+	}
+
+	ddl, err := conn.adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotIndex bool
+	for _, stmt := range ddl.Statements {
+		if strings.Contains(stmt, "TestExecContextDDLByB") {
+			gotIndex = true
+		}
+	}
+	if !gotIndex {
+		t.Errorf("index not created, schema: %v", ddl.Statements)
+	}
+
+	var b string
+	if err := db.QueryRowContext(ctx, `SELECT B FROM TestExecContextDDL WHERE A = "a2"`).Scan(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b != "b;2" {
+		t.Errorf("want: %q, got: %q", "b;2", b)
+	}
+
+	// DDL is not supported in transactions.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "DROP INDEX TestExecContextDDLByB"); err == nil {
+		t.Errorf("expected error for DDL in a transaction")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the index and table.
+	if _, err := db.ExecContext(ctx, "DROP INDEX TestExecContextDDLByB; DROP TABLE TestExecContextDDL"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecBatchDML(t *testing.T) {
+
+	// Set up test table.
+	conn, err := NewConnector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = executeDdlApi(conn, []string{
+		`CREATE TABLE TestExecBatchDML (
+			A   STRING(1024),
+			B   INT64
+		)	 PRIMARY KEY (A)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open db.
+	ctx := context.Background()
+	db, err := sql.Open("spanner", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Outside of a transaction.
+	res, err := ExecBatchDML(ctx, c, []spanner.Statement{
+		spanner.NewStatement(`INSERT INTO TestExecBatchDML (A, B) VALUES ("a1", 1)`),
+		spanner.NewStatement(`INSERT INTO TestExecBatchDML (A, B) VALUES ("a2", 2)`),
+		spanner.NewStatement(`UPDATE TestExecBatchDML SET B = B + 1 WHERE TRUE`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.BatchRowsAffected(), []int64{1, 1, 2}; !reflect.DeepEqual(want, got) {
+		t.Errorf("BatchRowsAffected: want: %v, got: %v", want, got)
+	}
+	if got, _ := res.RowsAffected(); got != 4 {
+		t.Errorf("RowsAffected: want: 4, got: %v", got)
+	}
+
+	// In a read-write transaction, rolled back.
+	tx, err := c.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = ExecBatchDML(ctx, c, []spanner.Statement{
+		spanner.NewStatement(`DELETE FROM TestExecBatchDML WHERE A = "a1"`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.BatchRowsAffected(), []int64{1}; !reflect.DeepEqual(want, got) {
+		t.Errorf("BatchRowsAffected in transaction: want: %v, got: %v", want, got)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := c.QueryRowContext(ctx, "SELECT COUNT(*) FROM TestExecBatchDML").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("rolled back batch was applied: want 2 rows, got %v", count)
+	}
+
+	// In a read-only transaction.
+	tx, err = c.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExecBatchDML(ctx, c, []spanner.Statement{
+		spanner.NewStatement(`DELETE FROM TestExecBatchDML WHERE TRUE`),
+	})
+	if err == nil {
+		t.Errorf("expected error for batch DML in a read-only transaction")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop table.
+	err = executeDdlApi(conn, []string{`DROP TABLE TestExecBatchDML`})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecPartitionedDML(t *testing.T) {
+
+	// Set up test table.
+	conn, err := NewConnector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = executeDdlApi(conn, []string{
+		`CREATE TABLE TestExecPartitionedDML (
+			A   STRING(1024),
+			B   INT64
+		)	 PRIMARY KEY (A)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ExecuteDMLClientLib([]string{`INSERT INTO TestExecPartitionedDML (A, B)
+		VALUES ("a1", 1), ("a2", 2), ("a3", 3)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open db.
+	ctx := context.Background()
+	db, err := sql.Open("spanner", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	res, err := ExecPartitionedDML(ctx, c, spanner.NewStatement(`UPDATE TestExecPartitionedDML SET B = 0 WHERE TRUE`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.RowsAffectedLowerBound(); got < 3 {
+		t.Errorf("RowsAffectedLowerBound: want at least 3, got: %v", got)
+	}
+
+	// Partitioned DML is not supported in transactions.
+	tx, err := c.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExecPartitionedDML(ctx, c, spanner.NewStatement(`UPDATE TestExecPartitionedDML SET B = 1 WHERE TRUE`))
+	if err == nil {
+		t.Errorf("expected error for partitioned DML in a transaction")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop table.
+	err = executeDdlApi(conn, []string{`DROP TABLE TestExecPartitionedDML`})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	msg := &sppb.Type{Code: sppb.TypeCode_INT64}
+	want, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nilMsg *sppb.Type
+
+	tests := []struct {
+		name    string
+		input   interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name:  "proto message",
+			input: msg,
+			want:  want,
+		},
+		{
+			name:  "nil proto message",
+			input: nilMsg,
+			want:  []byte(nil),
+		},
+		{
+			name:    "other value",
+			input:   int64(1),
+			want:    int64(1),
+			wantErr: driver.ErrSkip,
+		},
+	}
+
+	c := &conn{}
+	for _, tc := range tests {
+		v := &driver.NamedValue{Value: tc.input}
+		if err := c.CheckNamedValue(v); err != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(tc.want, v.Value) {
+			t.Errorf("Test failed: %s. want: %v, got: %v", tc.name, tc.want, v.Value)
+		}
+	}
+}
+
+func TestCheckNamedValueDeterministic(t *testing.T) {
+	msg := &structpb.Struct{Fields: make(map[string]*structpb.Value)}
+	for i := 0; i < 32; i++ {
+		msg.Fields[fmt.Sprintf("field%d", i)] = &structpb.Value{
+			Kind: &structpb.Value_NumberValue{NumberValue: float64(i)},
+		}
+	}
+
+	c := &conn{}
+	var first []byte
+	for i := 0; i < 10; i++ {
+		v := &driver.NamedValue{Value: msg}
+		if err := c.CheckNamedValue(v); err != nil {
+			t.Fatal(err)
+		}
+		got := v.Value.([]byte)
+		if first == nil {
+			first = got
+		} else if !bytes.Equal(first, got) {
+			t.Fatalf("CheckNamedValue: encoding of a message with a map is not deterministic")
+		}
+	}
+}
+
+func TestOpenConnector(t *testing.T) {
+	d := &Driver{}
+	c1, err := d.OpenConnector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := d.OpenConnector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c1 != c2 {
+		t.Errorf("OpenConnector: want a shared connector for the same name")
+	}
+	if c1.Driver() != d {
+		t.Errorf("Driver: want the driver that opened the connector")
+	}
+
+	if err := c1.(*connector).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.connectors[dsn]; !ok {
+		t.Errorf("Close: connector released while still in use")
+	}
+	if err := c2.(*connector).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.connectors[dsn]; ok {
+		t.Errorf("Close: connector not released")
+	}
+	if _, err := c2.Connect(context.Background()); err == nil {
+		t.Errorf("Connect: expected error on a closed connector")
+	}
+}
+
+func TestConnectorClients(t *testing.T) {
+	// The emulator host makes the clients skip credentials,
+	// no connection is made by this test.
+	if host, ok := os.LookupEnv("SPANNER_EMULATOR_HOST"); ok {
+		defer os.Setenv("SPANNER_EMULATOR_HOST", host)
+	} else {
+		defer os.Unsetenv("SPANNER_EMULATOR_HOST")
+	}
+	os.Setenv("SPANNER_EMULATOR_HOST", "localhost:1")
+
+	d := &Driver{}
+	dc, err := d.OpenConnector(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dc.(*connector)
+	defer c.Close()
+
+	conn1, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn2, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn1.(*conn).client != conn2.(*conn).client {
+		t.Errorf("Connect: want a shared client")
+	}
+
+	// database/sql before Go 1.17 never closes the connector,
+	// the last connection must release the client.
+	conn1.Close()
+	if c.client == nil {
+		t.Errorf("Close: client closed while still in use")
+	}
+	conn2.Close()
+	if c.client != nil {
+		t.Errorf("Close: client not closed with the last connection")
+	}
+
+	conn3, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn3.Close()
+	if conn3.(*conn).client == nil {
+		t.Errorf("Connect: want a new client after the last connection was closed")
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		input        string
+		wantDatabase string
+		wantParams   map[string]string
+		wantErr      bool
+	}{
+		{
+			input:        "projects/p/instances/i/databases/d",
+			wantDatabase: "projects/p/instances/i/databases/d",
+			wantParams:   map[string]string{},
+		},
+		{
+			input:        "projects/p/instances/i/databases/d;readOnlyStaleness=MAX_STALENESS 15s;",
+			wantDatabase: "projects/p/instances/i/databases/d",
+			wantParams:   map[string]string{"readOnlyStaleness": "MAX_STALENESS 15s"},
+		},
+		{
+			input:   "projects/p/instances/i/databases/d;readOnlyStaleness",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		database, params, err := parseDSN(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseDSN(%q): unexpected error: %v", tc.input, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if database != tc.wantDatabase {
+			t.Errorf("parseDSN(%q) database = %q, want %q", tc.input, database, tc.wantDatabase)
+		}
+		if !reflect.DeepEqual(params, tc.wantParams) {
+			t.Errorf("parseDSN(%q) params = %v, want %v", tc.input, params, tc.wantParams)
+		}
+	}
+}
+
+// registerTestDriver registers the driver of TestRegisterDriverWithName
+// once, as sql.Register panics if a name is registered twice.
+var registerTestDriver sync.Once
+
+func TestRegisterDriverWithName(t *testing.T) {
+	registerTestDriver.Do(func() {
+		RegisterDriverWithName("spanner-test", Config{
+			ClientConfig: spanner.ClientConfig{NumChannels: 4},
+			Options:      []option.ClientOption{option.WithoutAuthentication()},
+		})
+	})
+
+	db, err := sql.Open("spanner-test", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d, ok := db.Driver().(*Driver)
+	if !ok {
+		t.Fatalf("Driver: want *Driver, got %T", db.Driver())
+	}
+	if d.Config.NumChannels != 4 || len(d.Options) != 1 {
+		t.Errorf("Driver: configuration not used, got %+v", d)
+	}
+}
+
+func TestBeginTxTimestampBound(t *testing.T) {
+	ctx := context.WithValue(context.Background(), txOptionsKey{}, TxOptions{
+		TimestampBound: spanner.ExactStaleness(10 * time.Second),
+	})
+	c := &conn{}
+	if _, err := c.BeginTx(ctx, driver.TxOptions{}); err == nil {
+		t.Errorf("BeginTx: expected error for a timestamp bound in a read-write transaction")
+	}
+	if c.inTransaction() {
+		t.Errorf("BeginTx: transaction started despite the error")
+	}
+
+	for _, tb := range []spanner.TimestampBound{
+		spanner.MaxStaleness(10 * time.Second),
+		spanner.MinReadTimestamp(time.Now()),
+	} {
+		ctx := context.WithValue(context.Background(), txOptionsKey{}, TxOptions{
+			ReadOnly:       true,
+			TimestampBound: tb,
+		})
+		if _, err := c.BeginTx(ctx, driver.TxOptions{ReadOnly: true}); err == nil {
+			t.Errorf("BeginTx(%v): expected error for a single-use only bound", tb)
+		}
+		if c.inTransaction() {
+			t.Errorf("BeginTx(%v): transaction started despite the error", tb)
+		}
+	}
+}
@@ -4,8 +4,10 @@ go 1.14
 
 require (
 	cloud.google.com/go/spanner v1.2.1
+	github.com/golang/protobuf v1.3.3
 	github.com/jinzhu/gorm v1.9.12
 	golang.org/x/tools v0.0.0-20200221224223-e1da425f72fd // indirect
 	google.golang.org/api v0.17.0
 	google.golang.org/genproto v0.0.0-20200212174721-66ed5ce911ce
+	google.golang.org/grpc v1.27.1
 )
@@ -31,6 +31,9 @@ type RWConnector struct {
 	ExecIn  chan *RWExecMessage
 	ExecOut chan *RWExecMessage
 
+	BatchIn  chan *RWBatchMessage
+	BatchOut chan *RWBatchMessage
+
 	RollbackIn chan struct{}
 	CommitIn   chan struct{}
 	Errors     chan error // only for starting, commit and rollback
@@ -44,6 +47,8 @@ func NewRWConnector(ctx context.Context, c *spanner.Client) *RWConnector {
 		QueryOut:   make(chan *RWQueryMessage),
 		ExecIn:     make(chan *RWExecMessage),
 		ExecOut:    make(chan *RWExecMessage),
+		BatchIn:    make(chan *RWBatchMessage),
+		BatchOut:   make(chan *RWBatchMessage),
 		RollbackIn: make(chan struct{}),
 		CommitIn:   make(chan struct{}),
 		Errors:     make(chan error),
@@ -62,6 +67,9 @@ func NewRWConnector(ctx context.Context, c *spanner.Client) *RWConnector {
 			case msg := <-connector.ExecIn:
 				msg.Rows, msg.Error = tx.Update(msg.Ctx, msg.Stmt)
 				connector.ExecOut <- msg
+			case msg := <-connector.BatchIn:
+				msg.Rows, msg.Error = tx.BatchUpdate(msg.Ctx, msg.Stmts)
+				connector.BatchOut <- msg
 			case <-connector.RollbackIn:
 				return ErrAborted
 			case <-connector.CommitIn:
@@ -91,4 +99,12 @@ type RWExecMessage struct {
 	Error error // out
 }
 
+type RWBatchMessage struct {
+	Ctx   context.Context     // in
+	Stmts []spanner.Statement // in
+
+	Rows  []int64 // out
+	Error error   // out
+}
+
 var ErrAborted = errors.New("aborted")
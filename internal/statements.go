@@ -0,0 +1,100 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"strings"
+)
+
+// SplitStatements splits q into the statements separated by semicolons.
+// Comments are removed and empty statements are skipped. Semicolons
+// in string literals, quoted identifiers and comments are ignored.
+func SplitStatements(q string) []string {
+	var (
+		stmts []string
+		b     strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; {
+		case c == ';':
+			flush()
+		case c == '-' && strings.HasPrefix(q[i:], "--"), c == '#':
+			// Line comment.
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			// Block comment.
+			end := strings.Index(q[i+2:], "*/")
+			if end == -1 {
+				i = len(q)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			n := quotedLen(q[i:])
+			b.WriteString(q[i : i+n])
+			i += n - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// quotedLen returns the length of the quoted string or
+// identifier at the beginning of q, including the quotes.
+func quotedLen(q string) int {
+	quote := q[:1]
+	if q[0] != '`' && (strings.HasPrefix(q, "'''") || strings.HasPrefix(q, `"""`)) {
+		quote = q[:3]
+	}
+	for i := len(quote); i < len(q); i++ {
+		if q[i] == '\\' {
+			i++ // Skip the escaped character.
+			continue
+		}
+		if strings.HasPrefix(q[i:], quote) {
+			return i + len(quote)
+		}
+	}
+	return len(q)
+}
+
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP"}
+
+// IsDDL reports whether stmt is a DDL statement.
+// stmt is expected to have no leading comments.
+func IsDDL(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	for _, k := range ddlKeywords {
+		if strings.EqualFold(fields[0], k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "single statement",
+			input: "SELECT 1",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name:  "trailing semicolon",
+			input: "SELECT 1;\n",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name: "migration file",
+			input: `-- Create the tables.
+CREATE TABLE A (Id INT64) PRIMARY KEY (Id);
+/* The second table; interleaved. */
+CREATE TABLE B (Id INT64, BId INT64) PRIMARY KEY (Id, BId),
+  INTERLEAVE IN PARENT A;
+# Seed.
+INSERT INTO A (Id) VALUES (1);`,
+			want: []string{
+				"CREATE TABLE A (Id INT64) PRIMARY KEY (Id)",
+				"CREATE TABLE B (Id INT64, BId INT64) PRIMARY KEY (Id, BId),\n  INTERLEAVE IN PARENT A",
+				"INSERT INTO A (Id) VALUES (1)",
+			},
+		},
+		{
+			name:  "semicolons in literals",
+			input: `INSERT INTO T (A, B, C) VALUES ('a;', "b\";", '''c;'''); SELECT ` + "`x;`" + ` FROM T`,
+			want: []string{
+				`INSERT INTO T (A, B, C) VALUES ('a;', "b\";", '''c;''')`,
+				"SELECT `x;` FROM T",
+			},
+		},
+		{
+			name:  "comment markers in literals",
+			input: `SELECT '--', "#", '/*'`,
+			want:  []string{`SELECT '--', "#", '/*'`},
+		},
+		{
+			name:  "only comments",
+			input: "-- nothing\n;;",
+		},
+	}
+
+	for _, tc := range tests {
+		got := SplitStatements(tc.input)
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("Test failed: %s. want: %q, got: %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestIsDDL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{input: "CREATE TABLE A (Id INT64) PRIMARY KEY (Id)", want: true},
+		{input: "alter table A add column B STRING(MAX)", want: true},
+		{input: "\n DROP INDEX AByB", want: true},
+		{input: "INSERT INTO A (Id) VALUES (1)", want: false},
+		{input: "SELECT * FROM CREATED", want: false},
+		{input: "", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := IsDDL(tc.input); got != tc.want {
+			t.Errorf("IsDDL(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
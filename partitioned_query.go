@@ -0,0 +1,184 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// runPartitionedQuery executes RUN PARTITIONED QUERY. The query is split
+// into partitions that are executed in parallel and the rows of all
+// partitions are returned in no particular order.
+//
+// The partitions are read with the timestamp bound of single-use queries,
+// which must be a strong read, an exact staleness or a read timestamp.
+func runPartitionedQuery(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.inTransaction() {
+		return nil, errors.New("cannot run a partitioned query in a transaction")
+	}
+	tb := c.singleUseTimestampBound(ctx)
+	if singleUseOnly(tb) {
+		return nil, fmt.Errorf("timestamp bound %v is not supported by partitioned queries", tb)
+	}
+	ss, err := prepareSpannerStmt(params[0], args)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := c.client.BatchReadOnlyTransaction(ctx, tb)
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := tx.PartitionQuery(ctx, ss, spanner.PartitionOptions{})
+	if err != nil {
+		tx.Cleanup(ctx)
+		tx.Close()
+		return nil, err
+	}
+	it := newPartitionedIterator(ctx, &batchTransaction{tx: tx}, partitions)
+	return &rows{it: it, stringMode: c.stringMode}, nil
+}
+
+// partitionExecutor executes the partitions of a query.
+// It is implemented by batchTransaction.
+type partitionExecutor interface {
+	execute(ctx context.Context, p *spanner.Partition) rowIterator
+	// close releases the resources of the partitions.
+	close()
+}
+
+type batchTransaction struct {
+	tx *spanner.BatchReadOnlyTransaction
+}
+
+func (b *batchTransaction) execute(ctx context.Context, p *spanner.Partition) rowIterator {
+	return b.tx.Execute(ctx, p)
+}
+
+func (b *batchTransaction) close() {
+	b.tx.Cleanup(context.Background())
+	b.tx.Close()
+}
+
+// partitionedIterator merges the rows of partitions
+// that are executed in parallel.
+type partitionedIterator struct {
+	exec   partitionExecutor
+	rows   chan *spanner.Row
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error // first error of the partitions
+
+	stopOnce sync.Once
+}
+
+func newPartitionedIterator(ctx context.Context, exec partitionExecutor, partitions []*spanner.Partition) *partitionedIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &partitionedIterator{
+		exec:   exec,
+		rows:   make(chan *spanner.Row),
+		cancel: cancel,
+	}
+
+	queue := make(chan *spanner.Partition, len(partitions))
+	for _, p := range partitions {
+		queue <- p
+	}
+	close(queue)
+
+	workers := runtime.NumCPU()
+	if workers > len(partitions) {
+		workers = len(partitions)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				if err := it.send(ctx, exec.execute(ctx, p)); err != nil {
+					it.setErr(err)
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(it.rows)
+	}()
+	return it
+}
+
+// send sends the rows of a partition until they are exhausted
+// or ctx is done.
+func (it *partitionedIterator) send(ctx context.Context, ri rowIterator) error {
+	defer ri.Stop()
+	for {
+		row, err := ri.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case it.rows <- row:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (it *partitionedIterator) setErr(err error) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.err == nil {
+		it.err = err
+	}
+}
+
+func (it *partitionedIterator) Next() (*spanner.Row, error) {
+	if row, ok := <-it.rows; ok {
+		return row, nil
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.err != nil {
+		return nil, it.err
+	}
+	return nil, iterator.Done
+}
+
+// Stop cancels the partitions that are still running
+// and releases their resources.
+func (it *partitionedIterator) Stop() {
+	it.stopOnce.Do(func() {
+		it.cancel()
+		for range it.rows {
+			// Drain until all partitions have stopped.
+		}
+		it.exec.close()
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// fakePartitions executes partitions from a table of row counts and errors.
+type fakePartitions struct {
+	rows map[*spanner.Partition]int
+	errs map[*spanner.Partition]error
+
+	mu       sync.Mutex
+	executed int
+	stopped  int
+	closed   int
+}
+
+func (f *fakePartitions) execute(ctx context.Context, p *spanner.Partition) rowIterator {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.executed++
+	return &fakeRowIterator{ctx: ctx, f: f, n: f.rows[p], err: f.errs[p]}
+}
+
+func (f *fakePartitions) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed++
+}
+
+// fakeRowIterator returns n rows, then err or iterator.Done.
+type fakeRowIterator struct {
+	ctx context.Context
+	f   *fakePartitions
+	n   int
+	err error
+}
+
+func (it *fakeRowIterator) Next() (*spanner.Row, error) {
+	if err := it.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if it.n == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		return nil, iterator.Done
+	}
+	it.n--
+	return spanner.NewRow([]string{"n"}, []interface{}{int64(it.n)})
+}
+
+func (it *fakeRowIterator) Stop() {
+	it.f.mu.Lock()
+	defer it.f.mu.Unlock()
+	it.f.stopped++
+}
+
+func newPartitions(n int) []*spanner.Partition {
+	partitions := make([]*spanner.Partition, n)
+	for i := range partitions {
+		partitions[i] = &spanner.Partition{}
+	}
+	return partitions
+}
+
+// stopWithin stops it, and fails if it doesn't return in time.
+func stopWithin(t *testing.T, it *partitionedIterator) {
+	stopped := make(chan struct{})
+	go func() {
+		it.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Stop: blocked")
+	}
+}
+
+func TestPartitionedIterator(t *testing.T) {
+	partitions := newPartitions(3)
+	f := &fakePartitions{rows: map[*spanner.Partition]int{
+		partitions[0]: 2,
+		partitions[1]: 0,
+		partitions[2]: 3,
+	}}
+	it := newPartitionedIterator(context.Background(), f, partitions)
+
+	var got int
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: unexpected error: %v", err)
+		}
+		got++
+	}
+	if got != 5 {
+		t.Errorf("Next: want 5 rows, got %v", got)
+	}
+	stopWithin(t, it)
+	if f.stopped != 3 {
+		t.Errorf("Stop: want 3 stopped partitions, got %v", f.stopped)
+	}
+	if f.closed != 1 {
+		t.Errorf("Stop: want the partitions closed once, got %v", f.closed)
+	}
+}
+
+func TestPartitionedIteratorError(t *testing.T) {
+	wantErr := errors.New("partition failed")
+	partitions := newPartitions(2)
+	f := &fakePartitions{
+		rows: map[*spanner.Partition]int{
+			partitions[0]: 1,
+			partitions[1]: 100,
+		},
+		errs: map[*spanner.Partition]error{partitions[0]: wantErr},
+	}
+	it := newPartitionedIterator(context.Background(), f, partitions)
+
+	var err error
+	for err == nil {
+		_, err = it.Next()
+	}
+	if err != wantErr {
+		t.Errorf("Next: want error %v, got %v", wantErr, err)
+	}
+	stopWithin(t, it)
+	if f.stopped != f.executed {
+		t.Errorf("Stop: want %v stopped partitions, got %v", f.executed, f.stopped)
+	}
+	if f.closed != 1 {
+		t.Errorf("Stop: want the partitions closed once, got %v", f.closed)
+	}
+}
+
+func TestPartitionedIteratorStopEarly(t *testing.T) {
+	partitions := newPartitions(4)
+	f := &fakePartitions{rows: map[*spanner.Partition]int{}}
+	for _, p := range partitions {
+		f.rows[p] = 100
+	}
+	it := newPartitionedIterator(context.Background(), f, partitions)
+
+	if _, err := it.Next(); err != nil {
+		t.Fatalf("Next: unexpected error: %v", err)
+	}
+	stopWithin(t, it)
+	stopWithin(t, it)
+	if f.stopped != f.executed {
+		t.Errorf("Stop: want %v stopped partitions, got %v", f.executed, f.stopped)
+	}
+	if f.closed != 1 {
+		t.Errorf("Stop: want the partitions closed once, got %v", f.closed)
+	}
+}
+
+func TestPartitionedIteratorNoPartitions(t *testing.T) {
+	f := &fakePartitions{}
+	it := newPartitionedIterator(context.Background(), f, nil)
+
+	if _, err := it.Next(); err != iterator.Done {
+		t.Errorf("Next: want iterator.Done, got %v", err)
+	}
+	stopWithin(t, it)
+	if f.closed != 1 {
+		t.Errorf("Stop: want the partitions closed once, got %v", f.closed)
+	}
+}
+
+func TestRunPartitionedQueryTimestampBound(t *testing.T) {
+	for _, input := range []string{"MAX_STALENESS 15s", "MIN_READ_TIMESTAMP 2020-02-01T10:00:00Z"} {
+		staleness, err := parseStaleness(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &conn{readOnlyStaleness: staleness}
+		if _, err := c.QueryContext(context.Background(), "RUN PARTITIONED QUERY SELECT 1", nil); err == nil {
+			t.Errorf("%s: expected error for a partitioned query", input)
+		}
+	}
+}
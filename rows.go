@@ -18,16 +18,29 @@ import (
 	"database/sql/driver"
 	"io"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner"
+	"github.com/golang/protobuf/jsonpb"
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	"google.golang.org/api/iterator"
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
+// rowIterator is implemented by *spanner.RowIterator.
+type rowIterator interface {
+	Next() (*spanner.Row, error)
+	Stop()
+}
+
 type rows struct {
-	it *spanner.RowIterator
+	it    rowIterator
+	close func() // optional, called after the iterator is stopped
+
+	// stringMode returns all columns as strings, see STRING_MODE.
+	stringMode bool
 
 	colsOnce sync.Once
 	cols     []string
@@ -47,6 +60,9 @@ func (r *rows) Columns() []string {
 // Close closes the rows iterator.
 func (r *rows) Close() error {
 	r.it.Stop()
+	if r.close != nil {
+		r.close()
+	}
 	return nil
 }
 
@@ -93,6 +109,14 @@ func (r *rows) Next(dest []driver.Value) error {
 		if err := row.Column(i, &col); err != nil {
 			return err
 		}
+		if r.stringMode {
+			v, err := columnString(col)
+			if err != nil {
+				return err
+			}
+			dest[i] = v
+			continue
+		}
 		switch col.Type.Code {
 		case sppb.TypeCode_INT64:
 			var v spanner.NullInt64
@@ -147,3 +171,66 @@ func (r *rows) Next(dest []driver.Value) error {
 	}
 	return nil
 }
+
+// columnString returns the string representation of col, or nil if col
+// is NULL. Scalars are represented as in the Cloud Spanner API, such as
+// RFC 3339 timestamps and base64 encoded bytes; arrays and structs as
+// JSON arrays.
+func columnString(col spanner.GenericColumnValue) (driver.Value, error) {
+	switch v := col.Value.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		return nil, nil
+	case *structpb.Value_StringValue:
+		return v.StringValue, nil
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(v.BoolValue), nil
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(v.NumberValue, 'g', -1, 64), nil
+	default:
+		var m jsonpb.Marshaler
+		return m.MarshalToString(col.Value)
+	}
+}
+
+// staticRows are the rows of a result computed by the driver.
+type staticRows struct {
+	cols []string
+	vals [][]driver.Value
+
+	// stringMode returns all columns as strings, see STRING_MODE.
+	stringMode bool
+}
+
+func (r *staticRows) Columns() []string {
+	return r.cols
+}
+
+func (r *staticRows) Close() error {
+	return nil
+}
+
+func (r *staticRows) Next(dest []driver.Value) error {
+	if len(r.vals) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.vals[0])
+	r.vals = r.vals[1:]
+	if r.stringMode {
+		for i, v := range dest {
+			dest[i] = valueString(v)
+		}
+	}
+	return nil
+}
+
+// valueString returns the string representation of a value computed
+// by the driver, formatted like columnString does, or nil if v is nil.
+func valueString(v driver.Value) driver.Value {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return v
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+func TestColumnString(t *testing.T) {
+	stringValue := func(s string) *structpb.Value {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+	}
+
+	tests := []struct {
+		name  string
+		input spanner.GenericColumnValue
+		want  driver.Value
+	}{
+		{
+			name: "null",
+			input: spanner.GenericColumnValue{
+				Type:  &sppb.Type{Code: sppb.TypeCode_INT64},
+				Value: &structpb.Value{Kind: &structpb.Value_NullValue{}},
+			},
+			want: nil,
+		},
+		{
+			name: "int64",
+			input: spanner.GenericColumnValue{
+				Type:  &sppb.Type{Code: sppb.TypeCode_INT64},
+				Value: stringValue("9007199254740993"),
+			},
+			want: "9007199254740993",
+		},
+		{
+			name: "float64",
+			input: spanner.GenericColumnValue{
+				Type:  &sppb.Type{Code: sppb.TypeCode_FLOAT64},
+				Value: &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: 0.5}},
+			},
+			want: "0.5",
+		},
+		{
+			name: "bool",
+			input: spanner.GenericColumnValue{
+				Type:  &sppb.Type{Code: sppb.TypeCode_BOOL},
+				Value: &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}},
+			},
+			want: "true",
+		},
+		{
+			name: "timestamp",
+			input: spanner.GenericColumnValue{
+				Type:  &sppb.Type{Code: sppb.TypeCode_TIMESTAMP},
+				Value: stringValue("2020-02-01T10:00:00Z"),
+			},
+			want: "2020-02-01T10:00:00Z",
+		},
+		{
+			name: "array",
+			input: spanner.GenericColumnValue{
+				Type: &sppb.Type{
+					Code:             sppb.TypeCode_ARRAY,
+					ArrayElementType: &sppb.Type{Code: sppb.TypeCode_STRING},
+				},
+				Value: &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{
+					Values: []*structpb.Value{stringValue("a"), {Kind: &structpb.Value_NullValue{}}},
+				}}},
+			},
+			want: `["a",null]`,
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := columnString(tc.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Test failed: %s. want: %v, got: %v", tc.name, tc.want, got)
+		}
+	}
+}
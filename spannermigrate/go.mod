@@ -0,0 +1,14 @@
+module github.com/rakyll/go-sql-driver-spanner/spannermigrate
+
+go 1.14
+
+require (
+	cloud.google.com/go/spanner v1.2.1
+	github.com/golang-migrate/migrate/v4 v4.14.1
+	github.com/rakyll/go-sql-driver-spanner v0.0.0-00010101000000-000000000000
+	google.golang.org/api v0.17.0
+	google.golang.org/genproto v0.0.0-20200212174721-66ed5ce911ce
+	google.golang.org/grpc v1.27.1
+)
+
+replace github.com/rakyll/go-sql-driver-spanner => ../
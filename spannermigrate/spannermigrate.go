@@ -0,0 +1,370 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spannermigrate is a golang-migrate database driver for Cloud
+// Spanner built on the database/sql driver.
+//
+// Importing the package registers the driver for URLs such as
+//
+//	spannersql://projects/PROJECT/instances/INSTANCE/databases/DATABASE
+//
+// The x-migrations-table and x-lock-table query parameters change the
+// names of the tables used by the driver.
+//
+// Migration files may contain several DDL and DML statements separated by
+// semicolons. Consecutive DDL statements are applied as a single schema
+// update, see the database/sql driver for details.
+//
+// The applied version is stored in the schema_migrations table. Lock
+// inserts a row in the schema_migrations_lock table in a read-write
+// transaction, and fails with database.ErrLocked while another driver
+// holds the row. A lock left behind by a migration that crashed must be
+// removed by deleting the row.
+package spannermigrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	nurl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/golang-migrate/migrate/v4/database"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"google.golang.org/grpc/codes"
+)
+
+func init() {
+	database.Register("spannersql", &Driver{})
+}
+
+const (
+	// DefaultMigrationsTable is the default table of the applied version.
+	DefaultMigrationsTable = "schema_migrations"
+
+	// DefaultLockTable is the default table of the lock row.
+	DefaultLockTable = "schema_migrations_lock"
+)
+
+// Config is the configuration of a Driver.
+type Config struct {
+	// MigrationsTable is the table of the applied version,
+	// DefaultMigrationsTable if empty.
+	MigrationsTable string
+
+	// LockTable is the table of the lock row,
+	// DefaultLockTable if empty.
+	LockTable string
+}
+
+// Driver implements database.Driver.
+type Driver struct {
+	db      *sql.DB
+	closeDB bool // set if the driver opened db
+	config  Config
+
+	// owner identifies the driver in the lock row.
+	owner string
+}
+
+// WithInstance returns a driver for the database of db, which must be
+// opened with the database/sql driver. The tables of the driver are
+// created if they don't exist. Closing the driver doesn't close db.
+func WithInstance(db *sql.DB, config *Config) (database.Driver, error) {
+	d := &Driver{db: db}
+	if config != nil {
+		d.config = *config
+	}
+	if d.config.MigrationsTable == "" {
+		d.config.MigrationsTable = DefaultMigrationsTable
+	}
+	if d.config.LockTable == "" {
+		d.config.LockTable = DefaultLockTable
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	d.owner = hex.EncodeToString(b)
+
+	if err := d.ensureTables(context.Background()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Open opens a driver for a spannersql:// URL.
+func (d *Driver) Open(url string) (database.Driver, error) {
+	name, config, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("spanner", name)
+	if err != nil {
+		return nil, err
+	}
+	dd, err := WithInstance(db, config)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	dd.(*Driver).closeDB = true
+	return dd, nil
+}
+
+// parseURL returns the database name and the configuration of url.
+func parseURL(url string) (string, *Config, error) {
+	u, err := nurl.Parse(url)
+	if err != nil {
+		return "", nil, err
+	}
+	name := strings.TrimPrefix(u.Host+u.Path, "/")
+	if !strings.HasPrefix(name, "projects/") {
+		return "", nil, fmt.Errorf("invalid database %q, want projects/PROJECT/instances/INSTANCE/databases/DATABASE", name)
+	}
+	q := u.Query()
+	return name, &Config{
+		MigrationsTable: q.Get("x-migrations-table"),
+		LockTable:       q.Get("x-lock-table"),
+	}, nil
+}
+
+// ensureTables creates the migrations and lock tables if they don't exist.
+func (d *Driver) ensureTables(ctx context.Context) error {
+	var ddl []string
+	for _, t := range []struct {
+		name, ddl string
+	}{
+		{
+			name: d.config.MigrationsTable,
+			ddl: fmt.Sprintf(`CREATE TABLE %s (
+				version INT64 NOT NULL,
+				dirty BOOL NOT NULL
+			) PRIMARY KEY (version)`, d.config.MigrationsTable),
+		},
+		{
+			name: d.config.LockTable,
+			ddl: fmt.Sprintf(`CREATE TABLE %s (
+				id INT64 NOT NULL,
+				owner STRING(MAX) NOT NULL,
+				locked_at TIMESTAMP NOT NULL
+			) PRIMARY KEY (id)`, d.config.LockTable),
+		},
+	} {
+		var n int64
+		err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_catalog = '' AND table_schema = '' AND table_name = @name`, t.name).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			ddl = append(ddl, t.ddl)
+		}
+	}
+	if len(ddl) == 0 {
+		return nil
+	}
+	_, err := d.db.ExecContext(ctx, strings.Join(ddl, ";\n"))
+	return err
+}
+
+// Close closes the database if it was opened by Open.
+func (d *Driver) Close() error {
+	if d.closeDB {
+		return d.db.Close()
+	}
+	return nil
+}
+
+// Lock inserts the lock row in a read-write transaction. Cloud Spanner
+// lets only one driver insert it, the others get database.ErrLocked.
+func (d *Driver) Lock() error {
+	_, err := d.db.ExecContext(context.Background(),
+		fmt.Sprintf("INSERT INTO %s (id, owner, locked_at) VALUES (1, @owner, CURRENT_TIMESTAMP())", d.config.LockTable),
+		d.owner)
+	if spanner.ErrCode(err) == codes.AlreadyExists {
+		return database.ErrLocked
+	}
+	return err
+}
+
+// Unlock deletes the lock row inserted by Lock.
+func (d *Driver) Unlock() error {
+	res, err := d.db.ExecContext(context.Background(),
+		fmt.Sprintf("DELETE FROM %s WHERE id = 1 AND owner = @owner", d.config.LockTable),
+		d.owner)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return database.ErrNotLocked
+	}
+	return nil
+}
+
+// Run executes the statements of a migration file.
+func (d *Driver) Run(migration io.Reader) error {
+	query, err := ioutil.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(query)) == 0 {
+		return nil
+	}
+	if _, err := d.db.ExecContext(context.Background(), string(query)); err != nil {
+		return &database.Error{OrigErr: err, Err: "migration failed", Query: query}
+	}
+	return nil
+}
+
+// SetVersion replaces the applied version in a single read-write
+// transaction.
+func (d *Driver) SetVersion(version int, dirty bool) error {
+	ctx := context.Background()
+	c, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	stmts := []spanner.Statement{
+		spanner.NewStatement(fmt.Sprintf("DELETE FROM %s WHERE TRUE", d.config.MigrationsTable)),
+	}
+	if version >= 0 || (version == database.NilVersion && dirty) {
+		stmts = append(stmts, spanner.Statement{
+			SQL: fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES (@version, @dirty)", d.config.MigrationsTable),
+			Params: map[string]interface{}{
+				"version": int64(version),
+				"dirty":   dirty,
+			},
+		})
+	}
+	if _, err := spannerdriver.ExecBatchDML(ctx, c, stmts); err != nil {
+		return &database.Error{OrigErr: err, Err: "cannot set version " + strconv.Itoa(version)}
+	}
+	return nil
+}
+
+// Version returns the applied version, or database.NilVersion
+// if no migration was applied.
+func (d *Driver) Version() (version int, dirty bool, err error) {
+	var v int64
+	err = d.db.QueryRowContext(context.Background(),
+		fmt.Sprintf("SELECT version, dirty FROM %s LIMIT 1", d.config.MigrationsTable)).Scan(&v, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.NilVersion, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return int(v), dirty, nil
+}
+
+// Drop drops the indexes and tables of the database in a single schema
+// update, including the tables of the driver. Foreign keys are not
+// dropped and make the update fail.
+func (d *Driver) Drop() error {
+	ctx := context.Background()
+
+	var stmts []string
+	rows, err := d.db.QueryContext(ctx, `SELECT index_name FROM information_schema.indexes
+		WHERE table_catalog = '' AND table_schema = '' AND index_type = 'INDEX'`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		stmts = append(stmts, "DROP INDEX "+name)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	parents := make(map[string]string)
+	rows, err = d.db.QueryContext(ctx, `SELECT table_name, parent_table_name FROM information_schema.tables
+		WHERE table_catalog = '' AND table_schema = ''`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var parent sql.NullString
+		if err := rows.Scan(&name, &parent); err != nil {
+			rows.Close()
+			return err
+		}
+		parents[name] = parent.String
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, name := range dropOrder(parents) {
+		stmts = append(stmts, "DROP TABLE "+name)
+	}
+
+	if len(stmts) == 0 {
+		return nil
+	}
+	_, err = d.db.ExecContext(ctx, strings.Join(stmts, ";\n"))
+	return err
+}
+
+// dropOrder orders the tables so that the tables interleaved in a
+// parent are dropped before it. parents maps the tables to their parent,
+// or to an empty string for top-level tables.
+func dropOrder(parents map[string]string) []string {
+	children := make(map[string]int)
+	for _, parent := range parents {
+		if parent != "" {
+			children[parent]++
+		}
+	}
+	var order []string
+	dropped := make(map[string]bool)
+	for len(dropped) < len(parents) {
+		var leaves []string
+		for name := range parents {
+			if !dropped[name] && children[name] == 0 {
+				leaves = append(leaves, name)
+			}
+		}
+		if len(leaves) == 0 {
+			break // Unreachable for a valid schema.
+		}
+		// Map iteration is random, keep the statements stable.
+		sort.Strings(leaves)
+		for _, name := range leaves {
+			dropped[name] = true
+			if parent := parents[name]; parent != "" {
+				children[parent]--
+			}
+		}
+		order = append(order, leaves...)
+	}
+	return order
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannermigrate
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"github.com/golang-migrate/migrate/v4/database"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantName   string
+		wantConfig *Config
+		wantErr    bool
+	}{
+		{
+			name:       "database",
+			input:      "spannersql://projects/p/instances/i/databases/d",
+			wantName:   "projects/p/instances/i/databases/d",
+			wantConfig: &Config{},
+		},
+		{
+			name:     "tables",
+			input:    "spannersql://projects/p/instances/i/databases/d?x-migrations-table=versions&x-lock-table=versions_lock",
+			wantName: "projects/p/instances/i/databases/d",
+			wantConfig: &Config{
+				MigrationsTable: "versions",
+				LockTable:       "versions_lock",
+			},
+		},
+		{
+			name:    "no database",
+			input:   "spannersql://d",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		name, config, err := parseURL(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if name != tc.wantName {
+			t.Errorf("%s: want database %q, got %q", tc.name, tc.wantName, name)
+		}
+		if !reflect.DeepEqual(config, tc.wantConfig) {
+			t.Errorf("%s: want config %+v, got %+v", tc.name, tc.wantConfig, config)
+		}
+	}
+}
+
+func TestDropOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		parents map[string]string
+		want    []string
+	}{
+		{
+			name: "no tables",
+			want: nil,
+		},
+		{
+			name:    "top-level tables",
+			parents: map[string]string{"b": "", "a": ""},
+			want:    []string{"a", "b"},
+		},
+		{
+			name: "interleaved tables",
+			parents: map[string]string{
+				"singers": "",
+				"albums":  "singers",
+				"songs":   "albums",
+				"labels":  "",
+			},
+			want: []string{"labels", "songs", "albums", "singers"},
+		},
+	}
+
+	for _, tc := range tests {
+		if got := dropOrder(tc.parents); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: want %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+// createDatabase creates the database of the emulator tests. The tests
+// drop all its tables, so it is not shared with the driver tests.
+func createDatabase(ctx context.Context) (string, error) {
+	project, ok := os.LookupEnv("SPANNER_TEST_PROJECT")
+	if !ok {
+		project = "test-project"
+	}
+	instance, ok := os.LookupEnv("SPANNER_TEST_INSTANCE")
+	if !ok {
+		instance = "test-instance"
+	}
+	var opts []option.ClientOption
+	if host, ok := os.LookupEnv("SPANNER_EMULATOR_HOST"); ok {
+		opts = append(opts,
+			option.WithoutAuthentication(),
+			option.WithEndpoint(host),
+			option.WithGRPCDialOption(grpc.WithInsecure()))
+	}
+	adminClient, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer adminClient.Close()
+
+	parent := "projects/" + project + "/instances/" + instance
+	name := parent + "/databases/gomigratetest"
+	if _, err := adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: name}); err == nil {
+		return name, nil
+	}
+	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+		Parent:          parent,
+		CreateStatement: "CREATE DATABASE `gomigratetest`",
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := op.Wait(ctx); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func TestDriver(t *testing.T) {
+	name, err := createDatabase(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d1, err := (&Driver{}).Open("spannersql://" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d1.Close()
+	d2, err := (&Driver{}).Open("spannersql://" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Close()
+
+	// Locking.
+	if err := d1.Lock(); err != nil {
+		t.Fatalf("Lock: unexpected error: %v", err)
+	}
+	if err := d2.Lock(); err != database.ErrLocked {
+		t.Errorf("Lock: want database.ErrLocked while locked by another driver, got %v", err)
+	}
+	if err := d2.Unlock(); err != database.ErrNotLocked {
+		t.Errorf("Unlock: want database.ErrNotLocked for the lock of another driver, got %v", err)
+	}
+	if err := d1.Unlock(); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+	if err := d2.Lock(); err != nil {
+		t.Fatalf("Lock: unexpected error after Unlock: %v", err)
+	}
+	if err := d2.Unlock(); err != nil {
+		t.Fatalf("Unlock: unexpected error: %v", err)
+	}
+
+	// Migrations and versions.
+	if v, dirty, err := d1.Version(); err != nil || v != database.NilVersion || dirty {
+		t.Errorf("Version: want NilVersion, got %v, %v, %v", v, dirty, err)
+	}
+	err = d1.Run(strings.NewReader(`
+CREATE TABLE Singers (
+	SingerId INT64 NOT NULL,
+	Name STRING(MAX)
+) PRIMARY KEY (SingerId);
+CREATE TABLE Albums (
+	SingerId INT64 NOT NULL,
+	AlbumId INT64 NOT NULL
+) PRIMARY KEY (SingerId, AlbumId), INTERLEAVE IN PARENT Singers;
+CREATE INDEX SingersByName ON Singers (Name);
+INSERT INTO Singers (SingerId, Name) VALUES (1, 'Marc');
+`))
+	if err != nil {
+		t.Fatalf("Run: unexpected error: %v", err)
+	}
+	if err := d1.Run(strings.NewReader("CREATE TABLE")); err == nil {
+		t.Errorf("Run: expected error for an invalid migration")
+	}
+
+	for _, tc := range []struct {
+		version int
+		dirty   bool
+	}{
+		{version: 1, dirty: true},
+		{version: 1, dirty: false},
+		{version: database.NilVersion, dirty: true},
+	} {
+		if err := d1.SetVersion(tc.version, tc.dirty); err != nil {
+			t.Fatalf("SetVersion(%v, %v): unexpected error: %v", tc.version, tc.dirty, err)
+		}
+		v, dirty, err := d1.Version()
+		if err != nil || v != tc.version || dirty != tc.dirty {
+			t.Errorf("Version: want %v, %v, got %v, %v, %v", tc.version, tc.dirty, v, dirty, err)
+		}
+	}
+	if err := d1.SetVersion(database.NilVersion, false); err != nil {
+		t.Fatalf("SetVersion: unexpected error: %v", err)
+	}
+	if v, _, err := d1.Version(); err != nil || v != database.NilVersion {
+		t.Errorf("Version: want NilVersion after reset, got %v, %v", v, err)
+	}
+
+	// Drop.
+	if err := d1.Drop(); err != nil {
+		t.Fatalf("Drop: unexpected error: %v", err)
+	}
+	var n int64
+	err = d1.(*Driver).db.QueryRow(`SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_catalog = '' AND table_schema = ''`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("Drop: want no tables, got %v", n)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+type timestampBoundKey struct{}
+
+// WithTimestampBound returns a context that makes the queries executed
+// with it outside of a transaction read with the given timestamp bound.
+//
+// Use it for the bounds that are only supported by single-use reads,
+// such as spanner.MinReadTimestamp to read at least at the commit
+// timestamp of a known write, or spanner.MaxStaleness.
+// Queries with such a context fail in a transaction.
+func WithTimestampBound(ctx context.Context, tb spanner.TimestampBound) context.Context {
+	return context.WithValue(ctx, timestampBoundKey{}, tb)
+}
+
+func timestampBoundFromContext(ctx context.Context) (spanner.TimestampBound, bool) {
+	tb, ok := ctx.Value(timestampBoundKey{}).(spanner.TimestampBound)
+	return tb, ok
+}
+
+// staleness is the READ_ONLY_STALENESS of a connection, the timestamp
+// bound of the queries executed outside of a transaction.
+type staleness struct {
+	text string // normalized, empty for strong reads
+	tb   spanner.TimestampBound
+}
+
+func (s staleness) String() string {
+	if s.text == "" {
+		return "STRONG"
+	}
+	return s.text
+}
+
+// parseStaleness parses one of the following:
+//
+//	STRONG
+//	MIN_READ_TIMESTAMP <RFC 3339 timestamp>
+//	READ_TIMESTAMP <RFC 3339 timestamp>
+//	MAX_STALENESS <duration>
+//	EXACT_STALENESS <duration>
+//
+// Durations use the time.ParseDuration format, such as 15s or 100ms.
+func parseStaleness(text string) (staleness, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return staleness{}, fmt.Errorf("invalid read-only staleness %q", text)
+	}
+	mode := strings.ToUpper(fields[0])
+	if mode == "STRONG" && len(fields) == 1 {
+		return staleness{}, nil
+	}
+	if len(fields) != 2 {
+		return staleness{}, fmt.Errorf("invalid read-only staleness %q", text)
+	}
+	normalized := mode + " " + fields[1]
+
+	switch mode {
+	case "MIN_READ_TIMESTAMP", "READ_TIMESTAMP":
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return staleness{}, fmt.Errorf("invalid read-only staleness %q: %v", text, err)
+		}
+		if mode == "MIN_READ_TIMESTAMP" {
+			return staleness{text: normalized, tb: spanner.MinReadTimestamp(t)}, nil
+		}
+		return staleness{text: normalized, tb: spanner.ReadTimestamp(t)}, nil
+	case "MAX_STALENESS", "EXACT_STALENESS":
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return staleness{}, fmt.Errorf("invalid read-only staleness %q: %v", text, err)
+		}
+		if mode == "MAX_STALENESS" {
+			return staleness{text: normalized, tb: spanner.MaxStaleness(d)}, nil
+		}
+		return staleness{text: normalized, tb: spanner.ExactStaleness(d)}, nil
+	}
+	return staleness{}, fmt.Errorf("invalid read-only staleness %q", text)
+}
+
+// singleUseOnly reports whether Cloud Spanner accepts tb for single-use
+// reads only. TimestampBound doesn't export its mode, so it is read from
+// the String form.
+func singleUseOnly(tb spanner.TimestampBound) bool {
+	s := tb.String()
+	return strings.HasPrefix(s, "(maxStaleness:") || strings.HasPrefix(s, "(minReadTimestamp:")
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+)
+
+func TestParseStaleness(t *testing.T) {
+	ts, err := time.Parse(time.RFC3339Nano, "2020-02-01T10:00:00.123Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		wantText string
+		wantTb   spanner.TimestampBound
+		wantErr  bool
+	}{
+		{input: "STRONG", wantText: "STRONG", wantTb: spanner.StrongRead()},
+		{input: " strong ", wantText: "STRONG", wantTb: spanner.StrongRead()},
+		{input: "MAX_STALENESS 15s", wantText: "MAX_STALENESS 15s", wantTb: spanner.MaxStaleness(15 * time.Second)},
+		{input: "exact_staleness  100ms", wantText: "EXACT_STALENESS 100ms", wantTb: spanner.ExactStaleness(100 * time.Millisecond)},
+		{input: "MIN_READ_TIMESTAMP 2020-02-01T10:00:00.123Z", wantText: "MIN_READ_TIMESTAMP 2020-02-01T10:00:00.123Z", wantTb: spanner.MinReadTimestamp(ts)},
+		{input: "READ_TIMESTAMP 2020-02-01T10:00:00.123Z", wantText: "READ_TIMESTAMP 2020-02-01T10:00:00.123Z", wantTb: spanner.ReadTimestamp(ts)},
+		{input: "", wantErr: true},
+		{input: "STRONG 15s", wantErr: true},
+		{input: "MAX_STALENESS", wantErr: true},
+		{input: "MAX_STALENESS fifteen", wantErr: true},
+		{input: "READ_TIMESTAMP yesterday", wantErr: true},
+		{input: "LATEST 15s", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseStaleness(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseStaleness(%q): unexpected error: %v", tc.input, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got.String() != tc.wantText {
+			t.Errorf("parseStaleness(%q) = %q, want %q", tc.input, got.String(), tc.wantText)
+		}
+		if got.tb != tc.wantTb {
+			t.Errorf("parseStaleness(%q) = %v, want %v", tc.input, got.tb, tc.wantTb)
+		}
+	}
+}
+
+func TestSingleUseTimestampBound(t *testing.T) {
+	staleness, err := parseStaleness("EXACT_STALENESS 10s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &conn{readOnlyStaleness: staleness}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want spanner.TimestampBound
+	}{
+		{
+			name: "connection staleness",
+			ctx:  context.Background(),
+			want: spanner.ExactStaleness(10 * time.Second),
+		},
+		{
+			name: "context bound takes precedence",
+			ctx:  WithTimestampBound(context.Background(), spanner.MaxStaleness(15*time.Second)),
+			want: spanner.MaxStaleness(15 * time.Second),
+		},
+		{
+			name: "strong context bound takes precedence",
+			ctx:  WithTimestampBound(context.Background(), spanner.StrongRead()),
+			want: spanner.StrongRead(),
+		},
+	}
+
+	for _, tc := range tests {
+		if got := c.singleUseTimestampBound(tc.ctx); got != tc.want {
+			t.Errorf("%s: want %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestTimestampBoundInTransaction(t *testing.T) {
+	// A connector that serves the DML of a read-write
+	// transaction, and no queries.
+	rwc := &internal.RWConnector{
+		ExecIn:  make(chan *internal.RWExecMessage),
+		ExecOut: make(chan *internal.RWExecMessage),
+	}
+	go func() {
+		msg := <-rwc.ExecIn
+		msg.Rows = 1
+		rwc.ExecOut <- msg
+	}()
+	c := &conn{rwTx: &rwTx{connector: rwc}}
+
+	ctx := WithTimestampBound(context.Background(), spanner.MaxStaleness(15*time.Second))
+	if _, err := c.QueryContext(ctx, "SELECT 1", nil); err == nil {
+		t.Errorf("QueryContext: expected error for a timestamp bound in a transaction")
+	}
+
+	// DML doesn't read with a timestamp bound, the bound is ignored.
+	res, err := c.ExecContext(ctx, "UPDATE t SET a = 1 WHERE TRUE", nil)
+	if err != nil {
+		t.Fatalf("ExecContext: unexpected error: %v", err)
+	}
+	if got, _ := res.RowsAffected(); got != 1 {
+		t.Errorf("ExecContext: want 1 row affected, got %v", got)
+	}
+}
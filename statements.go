@@ -0,0 +1,224 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// clientSideStatement is a statement that is handled by the
+// driver instead of being sent to Cloud Spanner.
+type clientSideStatement struct {
+	name string
+	re   *regexp.Regexp
+
+	// Either exec or query is set, depending on whether
+	// the statement returns rows.
+	exec  func(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error)
+	query func(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error)
+}
+
+var clientSideStatements = []*clientSideStatement{
+	{
+		name:  "SHOW VARIABLE READ_TIMESTAMP",
+		re:    regexp.MustCompile(`(?is)^\s*SHOW\s+VARIABLE\s+READ_TIMESTAMP\s*;?\s*$`),
+		query: showReadTimestamp,
+	},
+	{
+		name:  "SHOW VARIABLE READ_ONLY_STALENESS",
+		re:    regexp.MustCompile(`(?is)^\s*SHOW\s+VARIABLE\s+READ_ONLY_STALENESS\s*;?\s*$`),
+		query: showReadOnlyStaleness,
+	},
+	{
+		name: "SET READ_ONLY_STALENESS",
+		re:   regexp.MustCompile(`(?is)^\s*SET\s+READ_ONLY_STALENESS\s*=\s*'([^']*)'\s*;?\s*$`),
+		exec: setReadOnlyStaleness,
+	},
+	{
+		name:  "SHOW VARIABLE STRING_MODE",
+		re:    regexp.MustCompile(`(?is)^\s*SHOW\s+VARIABLE\s+STRING_MODE\s*;?\s*$`),
+		query: showStringMode,
+	},
+	{
+		name: "SET STRING_MODE",
+		re:   regexp.MustCompile(`(?is)^\s*SET\s+STRING_MODE\s*=\s*(TRUE|FALSE)\s*;?\s*$`),
+		exec: setStringMode,
+	},
+	{
+		name: "BEGIN",
+		re:   regexp.MustCompile(`(?is)^\s*(?:BEGIN(?:\s+TRANSACTION)?|START\s+TRANSACTION)(?:\s+(READ\s+ONLY|READ\s+WRITE))?\s*;?\s*$`),
+		exec: begin,
+	},
+	{
+		name: "COMMIT",
+		re:   regexp.MustCompile(`(?is)^\s*COMMIT(?:\s+TRANSACTION)?\s*;?\s*$`),
+		exec: commit,
+	},
+	{
+		name: "ROLLBACK",
+		re:   regexp.MustCompile(`(?is)^\s*ROLLBACK(?:\s+TRANSACTION)?\s*;?\s*$`),
+		exec: rollback,
+	},
+	{
+		name: "SET TRANSACTION",
+		re:   regexp.MustCompile(`(?is)^\s*SET\s+TRANSACTION\s+(READ\s+ONLY|READ\s+WRITE)\s*;?\s*$`),
+		exec: setTransaction,
+	},
+	{
+		name:  "RUN PARTITIONED QUERY",
+		re:    regexp.MustCompile(`(?is)^\s*RUN\s+PARTITIONED\s+QUERY\s+(.+)$`),
+		query: runPartitionedQuery,
+	},
+}
+
+// parseClientSideStatement returns the client-side statement matching
+// query and its parameters, or nil if query should be sent to Cloud Spanner.
+func parseClientSideStatement(query string) (*clientSideStatement, []string) {
+	for _, cs := range clientSideStatements {
+		if m := cs.re.FindStringSubmatch(query); m != nil {
+			return cs, m[1:]
+		}
+	}
+	return nil, nil
+}
+
+func (cs *clientSideStatement) execContext(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	if cs.exec == nil {
+		return nil, fmt.Errorf("%s returns rows and cannot be executed, use a query instead", cs.name)
+	}
+	return cs.exec(ctx, c, params, args)
+}
+
+func (cs *clientSideStatement) queryContext(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	if cs.query == nil {
+		return nil, fmt.Errorf("%s doesn't return rows and cannot be queried, execute it instead", cs.name)
+	}
+	return cs.query(ctx, c, params, args)
+}
+
+func showReadTimestamp(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	var v driver.Value // NULL if there is no read timestamp
+	if ts := c.lastReadTimestamp(); !ts.IsZero() {
+		v = ts
+	}
+	return &staticRows{
+		cols:       []string{"READ_TIMESTAMP"},
+		vals:       [][]driver.Value{{v}},
+		stringMode: c.stringMode,
+	}, nil
+}
+
+func showReadOnlyStaleness(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	return &staticRows{
+		cols:       []string{"READ_ONLY_STALENESS"},
+		vals:       [][]driver.Value{{c.readOnlyStaleness.String()}},
+		stringMode: c.stringMode,
+	}, nil
+}
+
+func setReadOnlyStaleness(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	s, err := parseStaleness(params[0])
+	if err != nil {
+		return nil, err
+	}
+	c.readOnlyStaleness = s
+	return &result{}, nil
+}
+
+func showStringMode(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Rows, error) {
+	return &staticRows{
+		cols:       []string{"STRING_MODE"},
+		vals:       [][]driver.Value{{c.stringMode}},
+		stringMode: c.stringMode,
+	}, nil
+}
+
+// setStringMode sets whether query results return all columns as strings,
+// for generic tools that don't know the types of the columns.
+func setStringMode(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	c.stringMode = strings.EqualFold(params[0], "TRUE")
+	return &result{}, nil
+}
+
+// begin starts a transaction that is ended by COMMIT or ROLLBACK.
+// The transaction is read-only if requested by the statement or by
+// a preceding SET TRANSACTION READ ONLY.
+func begin(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	readOnly := c.txReadOnly
+	if params[0] != "" {
+		readOnly = isReadOnly(params[0])
+	}
+	// The transaction outlives the statement,
+	// so it cannot use the statement's context.
+	tx, err := c.BeginTx(context.Background(), driver.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return nil, err
+	}
+	c.tx = tx
+	c.txReadOnly = false
+	return &result{}, nil
+}
+
+func commit(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.checkClientSideTx(); err != nil {
+		return nil, err
+	}
+	err := c.tx.Commit()
+	c.tx = nil // The transaction is finished even if the commit failed.
+	if err != nil {
+		return nil, err
+	}
+	return &result{}, nil
+}
+
+func rollback(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.checkClientSideTx(); err != nil {
+		return nil, err
+	}
+	err := c.tx.Rollback()
+	c.tx = nil
+	if err != nil {
+		return nil, err
+	}
+	return &result{}, nil
+}
+
+// setTransaction sets the mode of the next transaction started with BEGIN.
+func setTransaction(ctx context.Context, c *conn, params []string, args []driver.NamedValue) (driver.Result, error) {
+	if c.inTransaction() {
+		return nil, errors.New("cannot change the mode of an active transaction")
+	}
+	c.txReadOnly = isReadOnly(params[0])
+	return &result{}, nil
+}
+
+func (c *conn) checkClientSideTx() error {
+	if c.tx != nil {
+		return nil
+	}
+	if c.inTransaction() {
+		return errors.New("transaction wasn't started with BEGIN, use sql.Tx to end it")
+	}
+	return errors.New("no transaction started with BEGIN")
+}
+
+func isReadOnly(mode string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(mode), " "), "READ ONLY")
+}
@@ -0,0 +1,324 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+)
+
+func TestParseClientSideStatement(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantName   string
+		wantParams []string
+	}{
+		{
+			name:       "show read timestamp",
+			input:      "SHOW VARIABLE READ_TIMESTAMP",
+			wantName:   "SHOW VARIABLE READ_TIMESTAMP",
+			wantParams: []string{},
+		},
+		{
+			name:       "show read timestamp lower case with semicolon",
+			input:      "  show variable\n read_timestamp ; ",
+			wantName:   "SHOW VARIABLE READ_TIMESTAMP",
+			wantParams: []string{},
+		},
+		{
+			name:       "set read-only staleness",
+			input:      "SET READ_ONLY_STALENESS = 'MAX_STALENESS 15s'",
+			wantName:   "SET READ_ONLY_STALENESS",
+			wantParams: []string{"MAX_STALENESS 15s"},
+		},
+		{
+			name:       "show read-only staleness",
+			input:      "show variable read_only_staleness",
+			wantName:   "SHOW VARIABLE READ_ONLY_STALENESS",
+			wantParams: []string{},
+		},
+		{
+			name:       "set string mode",
+			input:      "set string_mode = true;",
+			wantName:   "SET STRING_MODE",
+			wantParams: []string{"true"},
+		},
+		{
+			name:  "set string mode to invalid value",
+			input: "SET STRING_MODE = 1",
+		},
+		{
+			name:       "begin",
+			input:      "BEGIN",
+			wantName:   "BEGIN",
+			wantParams: []string{""},
+		},
+		{
+			name:       "begin read only",
+			input:      "begin transaction read only;",
+			wantName:   "BEGIN",
+			wantParams: []string{"read only"},
+		},
+		{
+			name:       "start transaction read write",
+			input:      "START TRANSACTION READ WRITE",
+			wantName:   "BEGIN",
+			wantParams: []string{"READ WRITE"},
+		},
+		{
+			name:       "commit",
+			input:      "COMMIT TRANSACTION",
+			wantName:   "COMMIT",
+			wantParams: []string{},
+		},
+		{
+			name:       "rollback",
+			input:      "rollback",
+			wantName:   "ROLLBACK",
+			wantParams: []string{},
+		},
+		{
+			name:       "set transaction read only",
+			input:      "SET TRANSACTION READ  ONLY",
+			wantName:   "SET TRANSACTION",
+			wantParams: []string{"READ  ONLY"},
+		},
+		{
+			name:  "set transaction without mode",
+			input: "SET TRANSACTION",
+		},
+		{
+			name:       "run partitioned query",
+			input:      "RUN PARTITIONED QUERY SELECT * FROM TestQueryContext\nWHERE A = @a",
+			wantName:   "RUN PARTITIONED QUERY",
+			wantParams: []string{"SELECT * FROM TestQueryContext\nWHERE A = @a"},
+		},
+		{
+			name:  "query",
+			input: "SELECT * FROM TestQueryContext",
+		},
+		{
+			name:  "unknown variable",
+			input: "SHOW VARIABLE READ_TIMESTAMPS",
+		},
+	}
+
+	for _, tc := range tests {
+		cs, params := parseClientSideStatement(tc.input)
+		var gotName string
+		if cs != nil {
+			gotName = cs.name
+		}
+		if gotName != tc.wantName {
+			t.Errorf("Test failed: %s. want statement: %q, got: %q", tc.name, tc.wantName, gotName)
+		}
+		if cs != nil && !reflect.DeepEqual(tc.wantParams, params) {
+			t.Errorf("Test failed: %s. want params: %q, got: %q", tc.name, tc.wantParams, params)
+		}
+	}
+}
+
+func TestSetTransaction(t *testing.T) {
+	c := &conn{}
+	if _, err := c.ExecContext(context.Background(), "SET TRANSACTION READ ONLY", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !c.txReadOnly {
+		t.Errorf("SET TRANSACTION READ ONLY: want read-only mode for the next transaction")
+	}
+	if _, err := c.ExecContext(context.Background(), "SET TRANSACTION READ WRITE", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.txReadOnly {
+		t.Errorf("SET TRANSACTION READ WRITE: want read-write mode for the next transaction")
+	}
+	if _, err := c.ExecContext(context.Background(), "COMMIT", nil); err == nil {
+		t.Errorf("COMMIT: expected error without a transaction")
+	}
+}
+
+func TestCommitFailureThenClose(t *testing.T) {
+	// A connector whose commit fails, after which
+	// it stops serving the transaction.
+	rwc := &internal.RWConnector{
+		CommitIn:   make(chan struct{}),
+		RollbackIn: make(chan struct{}),
+		Errors:     make(chan error),
+	}
+	go func() {
+		<-rwc.CommitIn
+		rwc.Errors <- errors.New("commit failed")
+	}()
+
+	c := &conn{connector: &connector{driver: &Driver{}, conns: 1}}
+	c.rwTx = &rwTx{
+		connector: rwc,
+		close: func() {
+			c.rwTx = nil
+		},
+	}
+	c.tx = c.rwTx
+
+	if _, err := c.ExecContext(context.Background(), "COMMIT", nil); err == nil {
+		t.Fatalf("COMMIT: expected error")
+	}
+	if c.tx != nil || c.inTransaction() {
+		t.Errorf("COMMIT: failed transaction still active")
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- c.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close: unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close: blocked after a failed COMMIT")
+	}
+}
+
+func TestResetSession(t *testing.T) {
+	initial, err := parseStaleness("MAX_STALENESS 15s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &conn{
+		connector:         &connector{readOnlyStaleness: initial},
+		readOnlyStaleness: initial,
+		readTimestamp:     time.Now(),
+	}
+	if _, err := c.ExecContext(context.Background(), "SET READ_ONLY_STALENESS = 'EXACT_STALENESS 10s'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(context.Background(), "SET STRING_MODE = TRUE", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.readOnlyStaleness != initial {
+		t.Errorf("ResetSession: want READ_ONLY_STALENESS %v, got %v", initial, c.readOnlyStaleness)
+	}
+	if c.stringMode {
+		t.Errorf("ResetSession: want STRING_MODE false")
+	}
+	if !c.readTimestamp.IsZero() {
+		t.Errorf("ResetSession: want no READ_TIMESTAMP, got %v", c.readTimestamp)
+	}
+}
+
+func TestResetSessionTransaction(t *testing.T) {
+	// A connector that acknowledges the rollback
+	// of the transaction left open by BEGIN.
+	rwc := &internal.RWConnector{
+		RollbackIn: make(chan struct{}),
+		Errors:     make(chan error),
+	}
+	rolledBack := make(chan struct{})
+	go func() {
+		<-rwc.RollbackIn
+		close(rolledBack)
+		rwc.Errors <- internal.ErrAborted
+	}()
+
+	c := &conn{
+		connector:  &connector{},
+		txReadOnly: true,
+	}
+	c.rwTx = &rwTx{
+		connector: rwc,
+		close: func() {
+			c.rwTx = nil
+		},
+	}
+	c.tx = c.rwTx
+
+	if err := c.ResetSession(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rolledBack:
+	default:
+		t.Errorf("ResetSession: transaction started with BEGIN not rolled back")
+	}
+	if c.tx != nil || c.inTransaction() {
+		t.Errorf("ResetSession: transaction still active")
+	}
+	if c.txReadOnly {
+		t.Errorf("ResetSession: want read-write mode for the next transaction")
+	}
+
+	// A rollback failure leaves the transaction
+	// in an unknown state.
+	rwc = &internal.RWConnector{
+		RollbackIn: make(chan struct{}),
+		Errors:     make(chan error),
+	}
+	go func() {
+		<-rwc.RollbackIn
+		rwc.Errors <- errors.New("rollback failed")
+	}()
+	c.rwTx = &rwTx{
+		connector: rwc,
+		close: func() {
+			c.rwTx = nil
+		},
+	}
+	c.tx = c.rwTx
+	if err := c.ResetSession(context.Background()); err != driver.ErrBadConn {
+		t.Errorf("ResetSession: want driver.ErrBadConn after a failed rollback, got %v", err)
+	}
+	if c.tx != nil || c.inTransaction() {
+		t.Errorf("ResetSession: failed transaction still active")
+	}
+}
+
+func TestShowVariableStringMode(t *testing.T) {
+	ts := time.Date(2020, 2, 1, 10, 0, 0, 0, time.UTC)
+	c := &conn{stringMode: true, readTimestamp: ts}
+
+	tests := []struct {
+		input string
+		want  driver.Value
+	}{
+		{input: "SHOW VARIABLE STRING_MODE", want: "true"},
+		{input: "SHOW VARIABLE READ_TIMESTAMP", want: "2020-02-01T10:00:00Z"},
+		{input: "SHOW VARIABLE READ_ONLY_STALENESS", want: "STRONG"},
+	}
+
+	for _, tc := range tests {
+		rows, err := c.QueryContext(context.Background(), tc.input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := make([]driver.Value, 1)
+		if err := rows.Next(dest); err != nil {
+			t.Fatal(err)
+		}
+		if dest[0] != tc.want {
+			t.Errorf("%s: want: %v, got: %v (%T)", tc.input, tc.want, dest[0], dest[0])
+		}
+	}
+}
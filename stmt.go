@@ -50,20 +50,7 @@ func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	ss, err := prepareSpannerStmt(s.query, args)
-	if err != nil {
-		return nil, err
-	}
-
-	var it *spanner.RowIterator
-	if s.conn.roTx != nil {
-		it = s.conn.roTx.Query(ctx, ss)
-	} else if s.conn.rwTx != nil {
-		it = s.conn.rwTx.Query(ctx, ss)
-	} else {
-		it = s.conn.client.Single().Query(ctx, ss)
-	}
-	return &rows{it: it}, nil
+	return s.conn.QueryContext(ctx, s.query, args)
 }
 
 func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement, error) {
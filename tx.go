@@ -16,11 +16,45 @@ package spannerdriver
 
 import (
 	"context"
+	"database/sql"
 
 	"cloud.google.com/go/spanner"
 	"github.com/rakyll/go-sql-driver-spanner/internal"
 )
 
+// TxOptions are the Cloud Spanner specific options of a transaction
+// started with BeginTx.
+type TxOptions struct {
+	// ReadOnly starts a read-only transaction if set.
+	ReadOnly bool
+
+	// TimestampBound is the timestamp bound of a read-only transaction.
+	// Read-only transactions do strong reads by default.
+	// Setting a bound for a read-write transaction is an error.
+	//
+	// Only spanner.StrongRead, spanner.ExactStaleness and
+	// spanner.ReadTimestamp are valid. Cloud Spanner allows
+	// spanner.MinReadTimestamp and spanner.MaxStaleness for single-use
+	// reads only, and BeginTx returns an error for them; see
+	// WithTimestampBound for single-use reads.
+	TimestampBound spanner.TimestampBound
+}
+
+type txOptionsKey struct{}
+
+// BeginTx starts a transaction on db with the given Cloud Spanner
+// specific options. Use it instead of db.BeginTx when the transaction
+// needs options that cannot be expressed with sql.TxOptions.
+func BeginTx(ctx context.Context, db *sql.DB, opts TxOptions) (*sql.Tx, error) {
+	ctx = context.WithValue(ctx, txOptionsKey{}, opts)
+	return db.BeginTx(ctx, &sql.TxOptions{ReadOnly: opts.ReadOnly})
+}
+
+func txOptionsFromContext(ctx context.Context) TxOptions {
+	opts, _ := ctx.Value(txOptionsKey{}).(TxOptions)
+	return opts
+}
+
 type roTx struct {
 	close func()
 }
@@ -58,20 +92,29 @@ func (tx *rwTx) ExecContext(ctx context.Context, stmt spanner.Statement) (int64,
 	return msg.Rows, msg.Error
 }
 
+func (tx *rwTx) BatchUpdate(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	tx.connector.BatchIn <- &internal.RWBatchMessage{
+		Ctx:   ctx,
+		Stmts: stmts,
+	}
+	msg := <-tx.connector.BatchOut
+	return msg.Rows, msg.Error
+}
+
+// Commit commits the transaction. The transaction is finished
+// once the connector reports the outcome, even if it failed.
 func (tx *rwTx) Commit() error {
 	tx.connector.CommitIn <- struct{}{}
 	err := <-tx.connector.Errors
-	if err == nil {
-		tx.close()
-	}
+	tx.close()
 	return err
 }
 
 func (tx *rwTx) Rollback() error {
 	tx.connector.RollbackIn <- struct{}{}
 	err := <-tx.connector.Errors
+	tx.close()
 	if err == internal.ErrAborted {
-		tx.close()
 		return nil
 	}
 	return err